@@ -2,7 +2,9 @@ package commands
 
 import (
 	"bytes"
+	"context"
 	"errors"
+	"fmt"
 	"io"
 	"regexp"
 	"strings"
@@ -13,39 +15,113 @@ import (
 var (
 	// Registry - table of registered command handlers
 	Registry map[string]Command
+
+	middlewares []Middleware
 )
 
 func init() {
 	Registry = make(map[string]Command)
 }
 
+// ExitCoder is an error a command may return to report an explicit exit
+// status, e.g. to an "exec" SSH request. Errors that don't implement it are
+// treated as exit status 1.
+type ExitCoder interface {
+	error
+	ExitCode() int
+}
+
 // Command - a table entry for registering a command
 type Command struct {
-	Run      func(io.Writer, []string) error // required
+	Name     string
+	Run      func(context.Context, io.Writer, []string) error            // required unless RunStdin is set
+	RunStdin func(context.Context, io.Writer, io.Reader, []string) error // optional, used instead of Run whenever set (stdin may be nil, e.g. in the interactive shell)
 	Complete func() []string
 }
 
+// Call invokes the command, preferring RunStdin over Run when the command
+// registered a stdin-aware handler.
+func (c Command) Call(ctx context.Context, w io.Writer, stdin io.Reader, args []string) error {
+	if c.RunStdin != nil {
+		return c.RunStdin(ctx, w, stdin, args)
+	}
+	return c.Run(ctx, w, args)
+}
+
+func (c Command) bound() bool {
+	return c.Run != nil || c.RunStdin != nil
+}
+
 // RegisterCommand - Add a command
-func RegisterCommand(name string, run func(io.Writer, []string) error, complete func() []string) {
-	Registry[name] = Command{Run: run, Complete: complete}
+func RegisterCommand(name string, run func(context.Context, io.Writer, []string) error, complete func() []string) {
+	Registry[name] = Command{Name: name, Run: run, Complete: complete}
+}
+
+// RegisterStdinCommand - Add a command that also wants access to stdin,
+// such as one fed input by a non-interactive "exec" SSH request.
+func RegisterStdinCommand(name string, run func(context.Context, io.Writer, io.Reader, []string) error, complete func() []string) {
+	Registry[name] = Command{Name: name, RunStdin: run, Complete: complete}
+}
+
+// Middleware wraps a Command with cross-cutting behavior, such as logging
+// or auditing. Middlewares registered via RegisterMiddleware are composed
+// around every command resolved by LookupCommand, in registration order:
+// the first-registered middleware is the outermost layer.
+type Middleware func(next Command) Command
+
+// RegisterMiddleware adds m to the chain applied to every command resolved
+// by LookupCommand.
+func RegisterMiddleware(m Middleware) {
+	middlewares = append(middlewares, m)
+}
+
+func applyMiddleware(c Command) Command {
+	for i := len(middlewares) - 1; i >= 0; i-- {
+		c = middlewares[i](c)
+	}
+	return c
+}
+
+// CallInfo carries per-invocation metadata that middleware can recover from
+// a command's context via CallInfoFromContext, such as the connection the
+// command is running on behalf of.
+type CallInfo struct {
+	RemoteAddr string
+	User       string
+}
+
+type callInfoKey struct{}
+
+// WithCallInfo returns a context carrying info, for middleware to observe.
+func WithCallInfo(ctx context.Context, info CallInfo) context.Context {
+	return context.WithValue(ctx, callInfoKey{}, info)
+}
+
+// CallInfoFromContext returns the CallInfo attached to ctx, if any.
+func CallInfoFromContext(ctx context.Context) (CallInfo, bool) {
+	info, ok := ctx.Value(callInfoKey{}).(CallInfo)
+	return info, ok
 }
 
 // LookupCommand - Find a command by prefix
 func LookupCommand(prefix string) (name string, c Command, ok bool) {
 	prefix = strings.ToLower(prefix)
 	if c, ok = Registry[prefix]; ok {
-		return prefix, c, ok
+		return prefix, applyMiddleware(c), ok
 	}
 	for full, candidate := range Registry {
 		if strings.HasPrefix(full, prefix) {
-			if c.Run != nil {
+			if c.bound() {
 				return "", Command{}, false
 			}
 			c = candidate
 			name = full
 		}
 	}
-	return name, c, c.Run != nil
+	if !c.bound() {
+		return name, c, false
+	}
+	return name, applyMiddleware(c), true
 }
 
 // AutoCompleteCallback - Callback for AutoCompletioon of Commands
@@ -104,9 +180,37 @@ func Exec(args string) (string, error) {
 	cmd, argv := f[0], f[1:]
 	b := new(bytes.Buffer)
 	if _, c, ok := LookupCommand(cmd); ok {
-		err = c.Run(b, argv)
+		err = c.Call(context.Background(), b, nil, argv)
 		return string(b.Bytes()), nil
 	}
 	t := "Unknown command: " + f[0] + "\n"
 	return t, errors.New(t)
 }
+
+// ExecTo parses and runs a command line non-interactively, writing its
+// output directly to w and, if the command registered a stdin-aware
+// handler, reading stdin from r. It returns the command's exit status:
+// 0 on success, 127 if the command is unknown, 1 for an error that doesn't
+// implement ExitCoder, or the status reported by ExitCoder otherwise.
+func ExecTo(ctx context.Context, w io.Writer, r io.Reader, args string) (int, error) {
+	f, err := shellwords.Parse(args)
+	if err != nil {
+		return 1, err
+	}
+	if len(f) == 0 {
+		return 0, nil
+	}
+	cmd, argv := f[0], f[1:]
+	_, c, ok := LookupCommand(cmd)
+	if !ok {
+		return 127, fmt.Errorf("unknown command: %s", cmd)
+	}
+	err = c.Call(ctx, w, r, argv)
+	if err == nil {
+		return 0, nil
+	}
+	if ec, ok := err.(ExitCoder); ok {
+		return ec.ExitCode(), err
+	}
+	return 1, err
+}
@@ -1,15 +1,20 @@
 package sshell
 
 import (
+	"context"
 	"crypto/subtle"
 	"encoding/binary"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
 	"log"
 	"net"
+	"os"
 	"strconv"
 	"strings"
+	"sync"
+	"time"
 
 	"golang.org/x/crypto/ssh/terminal"
 
@@ -18,6 +23,9 @@ import (
 	"golang.org/x/crypto/ssh"
 )
 
+// defaultHostKeyPath is used when SSHell.HostKeyPath is left unset.
+const defaultHostKeyPath = "./id_rsa"
+
 func init() {
 	commands.RegisterCommand("test", cmdTest, nil)
 	commands.RegisterCommand("exit", cmdExit, nil)
@@ -25,20 +33,187 @@ func init() {
 
 var errExitApp = errors.New("exiting")
 
+// KeyPermission describes what an authenticated public key is allowed to do.
+type KeyPermission struct {
+	Shell bool
+	SFTP  bool
+	Exec  bool
+	// SFTPReadOnly, when SFTP is true, restricts the SFTP subsystem to
+	// read-only operations (no writes, renames, deletes, or directory changes).
+	SFTPReadOnly bool
+}
+
+// KeyPolicy decides, for a given authorized key (identified by its SHA256
+// fingerprint and the comment from the authorized_keys entry), which
+// capabilities the connection is granted. If nil, all capabilities are allowed.
+type KeyPolicy func(fingerprint, comment string) KeyPermission
+
+// authorizedKey is one parsed entry from an authorized_keys file.
+type authorizedKey struct {
+	Comment string
+}
+
 // SSHell settings struct
 type SSHell struct {
 	User, Password string
 	Port           int
 	Running        bool
 	Prompt         string
+
+	// AuthorizedKeysPath, if set, enables public-key authentication against
+	// the keys listed in the given authorized_keys-format file.
+	AuthorizedKeysPath string
+	// HostKeyPath overrides the default host key location ("./id_rsa").
+	HostKeyPath string
+	// KeyPolicy, if set, is consulted for every public key accepted during
+	// authentication to decide which capabilities it may use.
+	KeyPolicy KeyPolicy
+
+	// SFTPBackend, if set, serves the SFTP subsystem out of this virtual
+	// filesystem instead of the native OS filesystem of the process.
+	SFTPBackend SFTPBackend
+	// SFTPDebug, if set, receives a trace of the SFTP protocol traffic. Only
+	// the native server (SFTPBackend nil) writes this trace; sessions served
+	// by SFTPBackend write none.
+	SFTPDebug io.Writer
+
+	// AllowPortForwarding, if set, is consulted for every direct-tcpip
+	// channel and tcpip-forward request with the authenticated user and the
+	// requested "host:port", to decide whether to allow it. If nil, no port
+	// forwarding is permitted.
+	AllowPortForwarding AllowPortForwarding
+
+	// AuditLogger, if set, receives one JSON line per executed command (see
+	// the built-in audit middleware registered in NewSSHell).
+	AuditLogger io.Writer
+}
+
+// AllowPortForwarding decides whether user may forward traffic to or from addr.
+type AllowPortForwarding func(user, addr string) bool
+
+// SFTPBackend is a virtual filesystem for the SFTP subsystem. It mirrors
+// sftp.Handlers so that github.com/pkg/sftp's request-based server can route
+// file, write, filesystem-command, and directory-listing requests to it.
+type SFTPBackend interface {
+	Fileread(*sftp.Request) (io.ReaderAt, error)
+	Filewrite(*sftp.Request) (io.WriterAt, error)
+	Filecmd(*sftp.Request) error
+	Filelist(*sftp.Request) (sftp.ListerAt, error)
+}
+
+// loadAuthorizedKeys parses an authorized_keys-format file into a map keyed
+// by the marshaled public key blob, for fast lookup during authentication.
+func loadAuthorizedKeys(path string) (map[string]authorizedKey, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	keys := make(map[string]authorizedKey)
+	for len(b) > 0 {
+		pubKey, comment, _, rest, err := ssh.ParseAuthorizedKey(b)
+		if err != nil {
+			return nil, err
+		}
+		keys[string(pubKey.Marshal())] = authorizedKey{Comment: comment}
+		b = rest
+	}
+	return keys, nil
 }
 
+// registerAuditMiddlewareOnce ensures auditMiddleware is only ever added to
+// the commands package's middleware chain once per process, no matter how many
+// SSHell instances NewSSHell creates.
+var registerAuditMiddlewareOnce sync.Once
+
 // NewSSHell - create a SSHell with default settings
 func NewSSHell() *SSHell {
 	s := new(SSHell)
+	registerAuditMiddlewareOnce.Do(func() {
+		commands.RegisterMiddleware(auditMiddleware)
+	})
 	return s
 }
 
+// auditRecord is one JSON line emitted per executed command by the audit middleware.
+type auditRecord struct {
+	Time     string   `json:"time"`
+	Remote   string   `json:"remote_addr"`
+	User     string   `json:"user"`
+	Command  string   `json:"command"`
+	Args     []string `json:"args"`
+	Error    string   `json:"error,omitempty"`
+	Duration string   `json:"duration"`
+}
+
+// instanceKey is the context key under which the SSHell instance serving the
+// current connection is stored, so the single, process-wide auditMiddleware
+// can log to the right instance's AuditLogger.
+type instanceKey struct{}
+
+// withInstance returns a context that auditMiddleware can trace back to s.
+func (s *SSHell) withInstance(ctx context.Context) context.Context {
+	return context.WithValue(ctx, instanceKey{}, s)
+}
+
+// instanceFromContext returns the SSHell that withInstance attached to ctx, if any.
+func instanceFromContext(ctx context.Context) (*SSHell, bool) {
+	s, ok := ctx.Value(instanceKey{}).(*SSHell)
+	return s, ok
+}
+
+// auditMiddleware is the built-in commands.Middleware that logs every
+// executed command to the owning SSHell's AuditLogger as a JSON line, giving
+// operators the equivalent of shell history / accounting without every
+// command having to opt in. It is a no-op whenever AuditLogger is nil, or
+// when ctx isn't associated with an SSHell (e.g. commands.Exec callers).
+func auditMiddleware(next commands.Command) commands.Command {
+	wrapped := next
+	if next.Run != nil {
+		wrapped.Run = func(ctx context.Context, w io.Writer, args []string) error {
+			start := time.Now()
+			err := next.Run(ctx, w, args)
+			if s, ok := instanceFromContext(ctx); ok {
+				s.logAudit(ctx, next.Name, args, err, time.Since(start))
+			}
+			return err
+		}
+	}
+	if next.RunStdin != nil {
+		wrapped.RunStdin = func(ctx context.Context, w io.Writer, stdin io.Reader, args []string) error {
+			start := time.Now()
+			err := next.RunStdin(ctx, w, stdin, args)
+			if s, ok := instanceFromContext(ctx); ok {
+				s.logAudit(ctx, next.Name, args, err, time.Since(start))
+			}
+			return err
+		}
+	}
+	return wrapped
+}
+
+func (s *SSHell) logAudit(ctx context.Context, name string, args []string, err error, duration time.Duration) {
+	if s.AuditLogger == nil {
+		return
+	}
+	info, _ := commands.CallInfoFromContext(ctx)
+	rec := auditRecord{
+		Time:     time.Now().UTC().Format(time.RFC3339Nano),
+		Remote:   info.RemoteAddr,
+		User:     info.User,
+		Command:  name,
+		Args:     args,
+		Duration: duration.String(),
+	}
+	if err != nil {
+		rec.Error = err.Error()
+	}
+	b, err := json.Marshal(rec)
+	if err != nil {
+		return
+	}
+	s.AuditLogger.Write(append(b, '\n'))
+}
+
 // Listen starts the server
 func (s *SSHell) Listen() {
 
@@ -52,9 +227,43 @@ func (s *SSHell) Listen() {
 			return nil, fmt.Errorf("password rejected for %q", c.User())
 		},
 	}
-	_, privateBytes, err := GetKeyPair("id_rsa")
+	if s.AuthorizedKeysPath != "" {
+		authorizedKeys, err := loadAuthorizedKeys(s.AuthorizedKeysPath)
+		if err != nil {
+			log.Fatalf("Failed to load authorized keys (%s): %s", s.AuthorizedKeysPath, err)
+		}
+		config.PublicKeyCallback = func(c ssh.ConnMetadata, pubKey ssh.PublicKey) (*ssh.Permissions, error) {
+			ak, ok := authorizedKeys[string(pubKey.Marshal())]
+			if !ok {
+				return nil, fmt.Errorf("public key rejected for %q", c.User())
+			}
+			fingerprint := ssh.FingerprintSHA256(pubKey)
+			log.Printf("Public key accepted for %q (%s) from %s\n", c.User(), fingerprint, c.RemoteAddr())
+
+			perm := KeyPermission{Shell: true, SFTP: true, Exec: true}
+			if s.KeyPolicy != nil {
+				perm = s.KeyPolicy(fingerprint, ak.Comment)
+			}
+			return &ssh.Permissions{
+				Extensions: map[string]string{
+					"fingerprint":   fingerprint,
+					"comment":       ak.Comment,
+					"shell":         strconv.FormatBool(perm.Shell),
+					"sftp":          strconv.FormatBool(perm.SFTP),
+					"exec":          strconv.FormatBool(perm.Exec),
+					"sftp-readonly": strconv.FormatBool(perm.SFTPReadOnly),
+				},
+			}, nil
+		}
+	}
+
+	hostKeyPath := s.HostKeyPath
+	if hostKeyPath == "" {
+		hostKeyPath = defaultHostKeyPath
+	}
+	_, privateBytes, err := GetKeyPair(hostKeyPath)
 	if err != nil {
-		log.Fatal("Failed to load private key (./id_rsa)")
+		log.Fatalf("Failed to load private key (%s)", hostKeyPath)
 	}
 	private, err := ssh.ParsePrivateKey(privateBytes)
 	if err != nil {
@@ -83,22 +292,57 @@ func (s *SSHell) Listen() {
 		}
 
 		log.Printf("New SSH connection from %s (%s)\n", sshConn.RemoteAddr(), sshConn.ClientVersion())
-		go ssh.DiscardRequests(reqs)
-		go s.handleChannels(chans)
+		go s.handleGlobalRequests(reqs, sshConn)
+		go s.handleChannels(chans, sshConn, sshConn.Permissions)
 	}
 }
 
-func (s *SSHell) handleChannels(chans <-chan ssh.NewChannel) {
+// allowed reports whether perms grants the named capability ("shell",
+// "sftp", or "exec"). Password-authenticated connections carry no
+// permissions and are allowed everything.
+func allowed(perms *ssh.Permissions, capability string) bool {
+	if perms == nil {
+		return true
+	}
+	v, ok := perms.Extensions[capability]
+	return !ok || v == "true"
+}
+
+// parseSSHString decodes the uint32-length-prefixed string used by SSH
+// request payloads such as "exec" and "subsystem" (RFC 4254 section 6.5),
+// reporting ok=false if payload is too short for the length prefix or the
+// declared length overruns what's left of it.
+func parseSSHString(payload []byte) (s string, ok bool) {
+	if len(payload) < 4 {
+		return "", false
+	}
+	n := binary.BigEndian.Uint32(payload)
+	if uint64(n) > uint64(len(payload)-4) {
+		return "", false
+	}
+	return string(payload[4 : 4+n]), true
+}
+
+func (s *SSHell) handleChannels(chans <-chan ssh.NewChannel, conn *ssh.ServerConn, perms *ssh.Permissions) {
 	for newChannel := range chans {
-		go s.handleChannel(newChannel)
+		go s.handleChannel(newChannel, conn, perms)
 	}
 }
 
-func (s *SSHell) handleChannel(newChannel ssh.NewChannel) {
-	if t := newChannel.ChannelType(); t != "session" {
-		newChannel.Reject(ssh.UnknownChannelType, fmt.Sprintf("unknown channel type: %s", t))
-		return
+func (s *SSHell) handleChannel(newChannel ssh.NewChannel, conn *ssh.ServerConn, perms *ssh.Permissions) {
+	switch newChannel.ChannelType() {
+	case "session":
+		s.handleSessionChannel(newChannel, conn, perms)
+	case "direct-tcpip":
+		s.handleDirectTCPIP(newChannel, conn)
+	default:
+		newChannel.Reject(ssh.UnknownChannelType, fmt.Sprintf("unknown channel type: %s", newChannel.ChannelType()))
 	}
+}
+
+// handleSessionChannel serves a "session" channel: a shell, an exec request,
+// or an SFTP subsystem.
+func (s *SSHell) handleSessionChannel(newChannel ssh.NewChannel, conn *ssh.ServerConn, perms *ssh.Permissions) {
 	connection, requests, err := newChannel.Accept()
 	if err != nil {
 		log.Printf("Could not accept channel (%s)", err)
@@ -109,22 +353,42 @@ func (s *SSHell) handleChannel(newChannel ssh.NewChannel) {
 	for req := range requests {
 		switch req.Type {
 		case "shell":
-			if len(req.Payload) == 0 {
+			if len(req.Payload) == 0 && allowed(perms, "shell") {
 				req.Reply(true, nil)
 				go func() {
 					defer connection.Close()
-					s.serveTerminal(connection, c)
+					s.serveTerminal(connection, c, conn)
 				}()
+			} else {
+				req.Reply(false, nil)
 			}
 		case "subsystem":
-			if string(req.Payload[4:]) == "sftp" {
+			name, ok := parseSSHString(req.Payload)
+			if ok && name == "sftp" && allowed(perms, "sftp") {
 				req.Reply(true, nil)
 				go func() {
 					defer connection.Close()
 					defer connection.CloseWrite()
-					s.serveSFTP(connection)
+					s.serveSFTP(connection, perms)
 				}()
+			} else {
+				req.Reply(false, nil)
+			}
+		case "exec":
+			if !allowed(perms, "exec") {
+				req.Reply(false, nil)
+				continue
+			}
+			cmdline, ok := parseSSHString(req.Payload)
+			if !ok {
+				req.Reply(false, nil)
+				continue
 			}
+			req.Reply(true, nil)
+			go func() {
+				defer connection.Close()
+				s.serveExec(connection, cmdline, conn)
+			}()
 		case "pty-req":
 			c <- req // we have not created the pty yet, pass along
 
@@ -134,10 +398,15 @@ func (s *SSHell) handleChannel(newChannel ssh.NewChannel) {
 	}
 }
 
-func (s *SSHell) serveTerminal(connection ssh.Channel, oldrequests <-chan *ssh.Request) {
+func (s *SSHell) serveTerminal(connection ssh.Channel, oldrequests <-chan *ssh.Request, conn *ssh.ServerConn) {
 
 	term := terminal.NewTerminal(connection, s.Prompt)
 	term.AutoCompleteCallback = commands.AutoCompleteCallback
+	ctx := commands.WithCallInfo(context.Background(), commands.CallInfo{
+		RemoteAddr: conn.RemoteAddr().String(),
+		User:       conn.User(),
+	})
+	ctx = s.withInstance(ctx)
 
 	go func() { // OOB requests
 		for req := range oldrequests {
@@ -166,7 +435,7 @@ func (s *SSHell) serveTerminal(connection ssh.Channel, oldrequests <-chan *ssh.R
 		}
 		cmd, args := f[0], f[1:]
 		if _, c, ok := commands.LookupCommand(cmd); ok {
-			err = c.Run(term, args)
+			err = c.Call(ctx, term, nil, args)
 			if err == errExitApp {
 				term.Write([]byte("Exiting." + "\n"))
 				return
@@ -177,10 +446,36 @@ func (s *SSHell) serveTerminal(connection ssh.Channel, oldrequests <-chan *ssh.R
 	}
 }
 
-func (s *SSHell) serveSFTP(channel ssh.Channel) {
+func (s *SSHell) serveSFTP(channel ssh.Channel, perms *ssh.Permissions) {
+	readOnly := perms != nil && perms.Extensions["sftp-readonly"] == "true"
 
-	serverOptions := []sftp.ServerOption{}
+	if s.SFTPBackend != nil {
+		handlers := sftp.Handlers{
+			FileGet:  s.SFTPBackend,
+			FilePut:  s.SFTPBackend,
+			FileCmd:  s.SFTPBackend,
+			FileList: s.SFTPBackend,
+		}
+		if readOnly {
+			handlers = readOnlyHandlers(handlers)
+		}
+		server := sftp.NewRequestServer(channel, handlers)
+		if err := server.Serve(); err == io.EOF {
+			server.Close()
+			log.Print("sftp client exited session.")
+		} else if err != nil {
+			log.Fatal("sftp server completed with error:", err)
+		}
+		return
+	}
 
+	var serverOptions []sftp.ServerOption
+	if s.SFTPDebug != nil {
+		serverOptions = append(serverOptions, sftp.WithDebug(s.SFTPDebug))
+	}
+	if readOnly {
+		serverOptions = append(serverOptions, sftp.ReadOnly())
+	}
 	server, err := sftp.NewServer(
 		channel,
 		serverOptions...,
@@ -196,13 +491,212 @@ func (s *SSHell) serveSFTP(channel ssh.Channel) {
 	}
 }
 
-func cmdTest(term io.Writer, args []string) error {
+// readOnlyHandlers wraps h so that all write and filesystem-command requests
+// are rejected, for sessions whose permissions only grant read access.
+func readOnlyHandlers(h sftp.Handlers) sftp.Handlers {
+	h.FilePut = rejectWriter{}
+	h.FileCmd = rejectCmder{}
+	return h
+}
+
+type rejectWriter struct{}
+
+func (rejectWriter) Filewrite(*sftp.Request) (io.WriterAt, error) {
+	return nil, os.ErrPermission
+}
+
+type rejectCmder struct{}
+
+func (rejectCmder) Filecmd(*sftp.Request) error {
+	return os.ErrPermission
+}
+
+// tcpipForwardPayload is the wire format of "tcpip-forward" and
+// "cancel-tcpip-forward" global request payloads (RFC 4254 section 7.1).
+type tcpipForwardPayload struct {
+	Addr string
+	Port uint32
+}
+
+// directTCPIPChannelData is the wire format of a "direct-tcpip" channel's
+// extra data, and of the "forwarded-tcpip" channel opened for each connection
+// accepted on a "tcpip-forward" listener (RFC 4254 section 7.2).
+type directTCPIPChannelData struct {
+	DestAddr   string
+	DestPort   uint32
+	OriginAddr string
+	OriginPort uint32
+}
+
+// handleGlobalRequests services connection-wide requests: currently
+// "tcpip-forward" and "cancel-tcpip-forward" for remote port forwarding.
+// Anything else is rejected, matching the previous ssh.DiscardRequests behavior.
+func (s *SSHell) handleGlobalRequests(reqs <-chan *ssh.Request, conn *ssh.ServerConn) {
+	listeners := make(map[string]net.Listener)
+	for req := range reqs {
+		switch req.Type {
+		case "tcpip-forward":
+			s.handleTCPIPForward(req, conn, listeners)
+		case "cancel-tcpip-forward":
+			s.handleCancelTCPIPForward(req, listeners)
+		default:
+			if req.WantReply {
+				req.Reply(false, nil)
+			}
+		}
+	}
+	for addr, l := range listeners {
+		l.Close()
+		delete(listeners, addr)
+	}
+}
+
+func (s *SSHell) handleTCPIPForward(req *ssh.Request, conn *ssh.ServerConn, listeners map[string]net.Listener) {
+	var payload tcpipForwardPayload
+	if err := ssh.Unmarshal(req.Payload, &payload); err != nil {
+		req.Reply(false, nil)
+		return
+	}
+	bindAddr := net.JoinHostPort(payload.Addr, strconv.Itoa(int(payload.Port)))
+	if s.AllowPortForwarding == nil || !s.AllowPortForwarding(conn.User(), bindAddr) {
+		req.Reply(false, nil)
+		return
+	}
+	listener, err := net.Listen("tcp", bindAddr)
+	if err != nil {
+		log.Printf("tcpip-forward: failed to listen on %s (%s)", bindAddr, err)
+		req.Reply(false, nil)
+		return
+	}
+	_, portString, _ := net.SplitHostPort(listener.Addr().String())
+	port, _ := strconv.Atoi(portString)
+	listeners[bindAddr] = listener
+
+	if req.WantReply {
+		req.Reply(true, ssh.Marshal(struct{ Port uint32 }{uint32(port)}))
+	}
+
+	go func() {
+		for {
+			tcpConn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go s.forwardTCPIP(conn, payload.Addr, uint32(port), tcpConn)
+		}
+	}()
+}
+
+func (s *SSHell) handleCancelTCPIPForward(req *ssh.Request, listeners map[string]net.Listener) {
+	var payload tcpipForwardPayload
+	if err := ssh.Unmarshal(req.Payload, &payload); err != nil {
+		req.Reply(false, nil)
+		return
+	}
+	bindAddr := net.JoinHostPort(payload.Addr, strconv.Itoa(int(payload.Port)))
+	listener, ok := listeners[bindAddr]
+	if !ok {
+		req.Reply(false, nil)
+		return
+	}
+	listener.Close()
+	delete(listeners, bindAddr)
+	req.Reply(true, nil)
+}
+
+// forwardTCPIP opens a "forwarded-tcpip" channel back to the client for a
+// connection accepted on a remotely-forwarded listener, then pipes the two
+// together until either side closes.
+func (s *SSHell) forwardTCPIP(conn *ssh.ServerConn, listenAddr string, listenPort uint32, tcpConn net.Conn) {
+	defer tcpConn.Close()
+	originAddr, originPortString, _ := net.SplitHostPort(tcpConn.RemoteAddr().String())
+	originPort, _ := strconv.Atoi(originPortString)
+
+	payload := directTCPIPChannelData{
+		DestAddr:   listenAddr,
+		DestPort:   listenPort,
+		OriginAddr: originAddr,
+		OriginPort: uint32(originPort),
+	}
+	channel, requests, err := conn.OpenChannel("forwarded-tcpip", ssh.Marshal(&payload))
+	if err != nil {
+		log.Printf("forwarded-tcpip: failed to open channel (%s)", err)
+		return
+	}
+	go ssh.DiscardRequests(requests)
+	pipe(channel, tcpConn)
+}
+
+// handleDirectTCPIP services a client-initiated "direct-tcpip" channel (the
+// backing channel type for local port forwarding): it dials the requested
+// destination and pipes the two together until either side closes.
+func (s *SSHell) handleDirectTCPIP(newChannel ssh.NewChannel, conn *ssh.ServerConn) {
+	var payload directTCPIPChannelData
+	if err := ssh.Unmarshal(newChannel.ExtraData(), &payload); err != nil {
+		newChannel.Reject(ssh.ConnectionFailed, "malformed direct-tcpip request")
+		return
+	}
+	destAddr := net.JoinHostPort(payload.DestAddr, strconv.Itoa(int(payload.DestPort)))
+	if s.AllowPortForwarding == nil || !s.AllowPortForwarding(conn.User(), destAddr) {
+		newChannel.Reject(ssh.Prohibited, "port forwarding not permitted")
+		return
+	}
+	targetConn, err := net.Dial("tcp", destAddr)
+	if err != nil {
+		newChannel.Reject(ssh.ConnectionFailed, err.Error())
+		return
+	}
+	channel, requests, err := newChannel.Accept()
+	if err != nil {
+		targetConn.Close()
+		log.Printf("Could not accept direct-tcpip channel (%s)", err)
+		return
+	}
+	go ssh.DiscardRequests(requests)
+	go pipe(channel, targetConn)
+}
+
+// pipe copies data in both directions between an SSH channel and a TCP
+// connection until either side closes, then closes both.
+func pipe(channel ssh.Channel, conn net.Conn) {
+	defer channel.Close()
+	defer conn.Close()
+	done := make(chan struct{}, 2)
+	go func() {
+		io.Copy(channel, conn)
+		done <- struct{}{}
+	}()
+	go func() {
+		io.Copy(conn, channel)
+		done <- struct{}{}
+	}()
+	<-done
+}
+
+// serveExec runs a single non-interactive command line for an "exec" SSH
+// request, sending its exit status back to the client once it completes.
+func (s *SSHell) serveExec(channel ssh.Channel, cmdline string, conn *ssh.ServerConn) {
+	ctx := commands.WithCallInfo(context.Background(), commands.CallInfo{
+		RemoteAddr: conn.RemoteAddr().String(),
+		User:       conn.User(),
+	})
+	ctx = s.withInstance(ctx)
+	status, err := commands.ExecTo(ctx, channel, channel, cmdline)
+	if err != nil {
+		fmt.Fprintln(channel.Stderr(), err)
+	}
+	var buf [4]byte
+	binary.BigEndian.PutUint32(buf[:], uint32(status))
+	channel.SendRequest("exit-status", false, buf[:])
+}
+
+func cmdTest(ctx context.Context, term io.Writer, args []string) error {
 	msg := fmt.Sprintf("Test: %+v", args)
 	term.Write([]byte(msg + "\n"))
 	return nil
 }
 
-func cmdExit(term io.Writer, args []string) error {
+func cmdExit(ctx context.Context, term io.Writer, args []string) error {
 	return errExitApp
 }
 
@@ -0,0 +1,41 @@
+package sshell
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"testing"
+
+	"github.com/awgh/sshell/commands"
+)
+
+func TestAuditMiddlewareScopedPerInstance(t *testing.T) {
+	commands.RegisterCommand("audittest", func(ctx context.Context, w io.Writer, args []string) error {
+		return nil
+	}, nil)
+
+	a := NewSSHell()
+	var bufA bytes.Buffer
+	a.AuditLogger = &bufA
+
+	b := NewSSHell()
+	var bufB bytes.Buffer
+	b.AuditLogger = &bufB
+
+	_, cmd, ok := commands.LookupCommand("audittest")
+	if !ok {
+		t.Fatal("audittest command not found")
+	}
+
+	ctx := a.withInstance(commands.WithCallInfo(context.Background(), commands.CallInfo{User: "alice"}))
+	if err := cmd.Call(ctx, io.Discard, nil, nil); err != nil {
+		t.Fatalf("Call: %v", err)
+	}
+
+	if bufA.Len() == 0 {
+		t.Error("expected a's AuditLogger to receive a log line for a's command")
+	}
+	if bufB.Len() != 0 {
+		t.Errorf("b's AuditLogger should not see a's command, got %q", bufB.String())
+	}
+}
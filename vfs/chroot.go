@@ -0,0 +1,112 @@
+package vfs
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/pkg/sftp"
+)
+
+// Chroot is an SFTP backend that jails every path under Root: a client can
+// never read, write, or list anything outside of it, regardless of how many
+// ".."s it asks for.
+type Chroot struct {
+	Root string
+}
+
+// NewChroot creates a backend rooted at root. The directory must already exist.
+func NewChroot(root string) *Chroot {
+	return &Chroot{Root: root}
+}
+
+// realPath maps a client-visible path (always rooted at "/") onto the real
+// filesystem beneath Root.
+func (c *Chroot) realPath(p string) string {
+	return filepath.Join(c.Root, filepath.Clean("/"+p))
+}
+
+// Fileread implements sshell.SFTPBackend.
+func (c *Chroot) Fileread(r *sftp.Request) (io.ReaderAt, error) {
+	return os.Open(c.realPath(r.Filepath))
+}
+
+// Filewrite implements sshell.SFTPBackend.
+func (c *Chroot) Filewrite(r *sftp.Request) (io.WriterAt, error) {
+	flags := os.O_WRONLY | os.O_CREATE
+	pflags := r.Pflags()
+	if pflags.Append {
+		flags |= os.O_APPEND
+	}
+	if pflags.Trunc {
+		flags |= os.O_TRUNC
+	}
+	if pflags.Excl {
+		flags |= os.O_EXCL
+	}
+	return os.OpenFile(c.realPath(r.Filepath), flags, 0644)
+}
+
+// Filecmd implements sshell.SFTPBackend.
+func (c *Chroot) Filecmd(r *sftp.Request) error {
+	path := c.realPath(r.Filepath)
+	switch r.Method {
+	case "Setstat":
+		return nil
+	case "Rename":
+		return os.Rename(path, c.realPath(r.Target))
+	case "Rmdir", "Remove":
+		return os.Remove(path)
+	case "Mkdir":
+		return os.Mkdir(path, 0755)
+	case "Symlink":
+		// Per pkg/sftp convention, r.Filepath is the symlink's target and
+		// r.Target is the path of the link itself.
+		return os.Symlink(path, c.realPath(r.Target))
+	default:
+		return sftp.ErrSSHFxOpUnsupported
+	}
+}
+
+// Filelist implements sshell.SFTPBackend.
+func (c *Chroot) Filelist(r *sftp.Request) (sftp.ListerAt, error) {
+	path := c.realPath(r.Filepath)
+	switch r.Method {
+	case "List":
+		entries, err := os.ReadDir(path)
+		if err != nil {
+			return nil, err
+		}
+		infos := make(fileInfoLister, 0, len(entries))
+		for _, e := range entries {
+			info, err := e.Info()
+			if err != nil {
+				return nil, err
+			}
+			infos = append(infos, info)
+		}
+		return infos, nil
+	case "Stat":
+		info, err := os.Stat(path)
+		if err != nil {
+			return nil, err
+		}
+		return fileInfoLister{info}, nil
+	default:
+		return nil, sftp.ErrSSHFxOpUnsupported
+	}
+}
+
+// fileInfoLister adapts a slice of os.FileInfo to sftp.ListerAt.
+type fileInfoLister []os.FileInfo
+
+func (l fileInfoLister) ListAt(dst []os.FileInfo, offset int64) (int, error) {
+	if offset >= int64(len(l)) {
+		return 0, io.EOF
+	}
+	n := copy(dst, l[offset:])
+	if n < len(dst) {
+		return n, io.EOF
+	}
+	return n, nil
+}
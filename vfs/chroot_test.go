@@ -0,0 +1,94 @@
+package vfs
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/pkg/sftp"
+)
+
+func TestChrootFileReadWrite(t *testing.T) {
+	root := t.TempDir()
+	c := NewChroot(root)
+
+	w, err := c.Filewrite(&sftp.Request{Method: "Put", Filepath: "/hello.txt"})
+	if err != nil {
+		t.Fatalf("Filewrite: %v", err)
+	}
+	if _, err := w.WriteAt([]byte("hello"), 0); err != nil {
+		t.Fatalf("WriteAt: %v", err)
+	}
+	if closer, ok := w.(interface{ Close() error }); ok {
+		closer.Close()
+	}
+
+	r, err := c.Fileread(&sftp.Request{Method: "Get", Filepath: "/hello.txt"})
+	if err != nil {
+		t.Fatalf("Fileread: %v", err)
+	}
+	buf := make([]byte, 5)
+	if _, err := r.ReadAt(buf, 0); err != nil {
+		t.Fatalf("ReadAt: %v", err)
+	}
+	if string(buf) != "hello" {
+		t.Errorf("read %q, want %q", buf, "hello")
+	}
+}
+
+func TestChrootFilecmdSymlink(t *testing.T) {
+	root := t.TempDir()
+	if err := os.WriteFile(filepath.Join(root, "target.txt"), []byte("hi"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	c := NewChroot(root)
+
+	err := c.Filecmd(&sftp.Request{
+		Method:   "Symlink",
+		Filepath: "/target.txt",
+		Target:   "/link.txt",
+	})
+	if err != nil {
+		t.Fatalf("Filecmd(Symlink): %v", err)
+	}
+
+	got, err := os.Readlink(filepath.Join(root, "link.txt"))
+	if err != nil {
+		t.Fatalf("Readlink(link.txt): %v", err)
+	}
+	if want := filepath.Join(root, "target.txt"); got != want {
+		t.Errorf("link points to %q, want %q", got, want)
+	}
+}
+
+func TestChrootFilelist(t *testing.T) {
+	root := t.TempDir()
+	if err := os.WriteFile(filepath.Join(root, "a.txt"), nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	c := NewChroot(root)
+
+	lister, err := c.Filelist(&sftp.Request{Method: "List", Filepath: "/"})
+	if err != nil {
+		t.Fatalf("Filelist(List): %v", err)
+	}
+	entries := make([]os.FileInfo, 1)
+	n, err := lister.ListAt(entries, 0)
+	if err != nil && err != io.EOF {
+		t.Fatalf("ListAt: %v", err)
+	}
+	if n != 1 || entries[0].Name() != "a.txt" {
+		t.Errorf("ListAt returned %d entries %v, want [a.txt]", n, entries)
+	}
+}
+
+func TestChrootEscapeConfinedToRoot(t *testing.T) {
+	root := t.TempDir()
+	c := NewChroot(root)
+	got := c.realPath("/../../../etc/passwd")
+	if !strings.HasPrefix(got, root) {
+		t.Errorf("realPath escaped root: %q", got)
+	}
+}
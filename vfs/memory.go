@@ -0,0 +1,39 @@
+package vfs
+
+import (
+	"io"
+
+	"github.com/pkg/sftp"
+)
+
+// Memory is an in-memory SFTP backend. State does not survive process
+// restart, which makes it handy for tests and demos rather than production
+// storage.
+type Memory struct {
+	handlers sftp.Handlers
+}
+
+// NewMemory creates an empty in-memory backend.
+func NewMemory() *Memory {
+	return &Memory{handlers: sftp.InMemHandler()}
+}
+
+// Fileread implements sshell.SFTPBackend.
+func (m *Memory) Fileread(r *sftp.Request) (io.ReaderAt, error) {
+	return m.handlers.FileGet.Fileread(r)
+}
+
+// Filewrite implements sshell.SFTPBackend.
+func (m *Memory) Filewrite(r *sftp.Request) (io.WriterAt, error) {
+	return m.handlers.FilePut.Filewrite(r)
+}
+
+// Filecmd implements sshell.SFTPBackend.
+func (m *Memory) Filecmd(r *sftp.Request) error {
+	return m.handlers.FileCmd.Filecmd(r)
+}
+
+// Filelist implements sshell.SFTPBackend.
+func (m *Memory) Filelist(r *sftp.Request) (sftp.ListerAt, error) {
+	return m.handlers.FileList.Filelist(r)
+}
@@ -0,0 +1,42 @@
+package vfs
+
+import (
+	"testing"
+
+	"github.com/pkg/sftp"
+)
+
+// sshFxfRead, sshFxfWrite, and sshFxfCreat are the SSH_FXF_READ,
+// SSH_FXF_WRITE, and SSH_FXF_CREAT bits from the SFTP Open packet's pflags
+// field (draft-ietf-secsh-filexfer section 6.3); sftp.Request.Pflags()
+// decodes a request's Flags against them to tell Fileread/Filewrite whether
+// the request is allowed to read or write.
+const (
+	sshFxfRead  = 0x00000001
+	sshFxfWrite = 0x00000002
+	sshFxfCreat = 0x00000008
+)
+
+func TestMemoryFileReadWrite(t *testing.T) {
+	m := NewMemory()
+
+	w, err := m.Filewrite(&sftp.Request{Method: "Put", Filepath: "/hello.txt", Flags: sshFxfWrite | sshFxfCreat})
+	if err != nil {
+		t.Fatalf("Filewrite: %v", err)
+	}
+	if _, err := w.WriteAt([]byte("hello"), 0); err != nil {
+		t.Fatalf("WriteAt: %v", err)
+	}
+
+	r, err := m.Fileread(&sftp.Request{Method: "Get", Filepath: "/hello.txt", Flags: sshFxfRead})
+	if err != nil {
+		t.Fatalf("Fileread: %v", err)
+	}
+	buf := make([]byte, 5)
+	if _, err := r.ReadAt(buf, 0); err != nil {
+		t.Fatalf("ReadAt: %v", err)
+	}
+	if string(buf) != "hello" {
+		t.Errorf("read %q, want %q", buf, "hello")
+	}
+}